@@ -0,0 +1,88 @@
+package sigprof
+
+import (
+	"io"
+	"sync"
+)
+
+// Options configures a Sigprof instance created with New.
+type Options struct {
+	// Triggers maps a platform-specific trigger name ("usr1", "usr2" on
+	// Unix; "interrupt" on Windows) to the list of profile names it
+	// should capture. See sigprof.go and sigprof_windows.go for the
+	// trigger names each platform supports.
+	Triggers map[string][]string
+
+	// Output selects where captured profiles are written: "file" (the
+	// default), "stdout", or "stderr".
+	Output string
+}
+
+// Sigprof is a programmatic, cross-platform handle for triggering
+// profile captures without relying on POSIX signals.
+type Sigprof struct {
+	opts Options
+
+	writerFactory   func(profile string, output outputType) io.WriteCloser
+	profilerFactory func() profiler
+
+	// profilerOnce/profiler cache the single profiler instance used for
+	// every capture, so stateful profilers (e.g. deltaProfiler) retain
+	// state across captures instead of starting fresh each time.
+	profilerOnce sync.Once
+	profiler     profiler
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// New creates a Sigprof configured with opts. Call Start to begin
+// listening for the platform's triggers, or call Trigger directly to
+// capture profiles on demand.
+func New(opts Options) *Sigprof {
+	return &Sigprof{
+		opts:            opts,
+		writerFactory:   newWriter,
+		profilerFactory: newDefaultProfiler,
+	}
+}
+
+// Start begins listening for the platform's triggers in the
+// background.
+func (s *Sigprof) Start() {
+	s.start()
+}
+
+// Stop ends the listener started by Start. It is safe to call even if
+// Start was never called, and safe to call more than once.
+func (s *Sigprof) Stop() {
+	s.stopOnce.Do(func() {
+		if s.stopCh != nil {
+			close(s.stopCh)
+		}
+	})
+}
+
+// Trigger immediately captures the given profiles.
+func (s *Sigprof) Trigger(profiles ...string) {
+	for _, profile := range profiles {
+		w := s.writerFactory(profile, s.output())
+		capture(s.getProfiler(), profile, w)
+	}
+}
+
+// getProfiler lazily constructs the single profiler instance used for
+// every capture made by s.
+func (s *Sigprof) getProfiler() profiler {
+	s.profilerOnce.Do(func() {
+		s.profiler = s.profilerFactory()
+	})
+	return s.profiler
+}
+
+func (s *Sigprof) output() outputType {
+	if s.opts.Output == "" {
+		return fileOutput
+	}
+	return outputType(s.opts.Output)
+}
@@ -0,0 +1,58 @@
+package sigprof
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestSigprofTrigger(t *testing.T) {
+	outputs := map[string]*bytes.Buffer{}
+	s := &Sigprof{
+		opts: Options{Output: "orange"},
+		writerFactory: func(profile string, out outputType) io.WriteCloser {
+			if out != "orange" {
+				t.Fatalf("unexpected output %q", out)
+			}
+			var buf bytes.Buffer
+			outputs[profile] = &buf
+			return bufferCloser{&buf}
+		},
+		profilerFactory: func() profiler {
+			return testProfiler{}
+		},
+	}
+
+	s.Trigger("foo", "bar")
+
+	for _, profile := range []string{"foo", "bar"} {
+		buf, ok := outputs[profile]
+		if !ok {
+			t.Errorf("missing expected profile %q", profile)
+			continue
+		}
+		if buf.String() != "test "+profile+"\n" {
+			t.Errorf("unexpected profiler contents: %q", buf.String())
+		}
+	}
+}
+
+func TestSigprofDefaultOutput(t *testing.T) {
+	s := New(Options{})
+	if s.output() != fileOutput {
+		t.Errorf("expected default output %q, got %q", fileOutput, s.output())
+	}
+}
+
+type bufferCloser struct {
+	*bytes.Buffer
+}
+
+func (bufferCloser) Close() error { return nil }
+
+type testProfiler struct{}
+
+func (testProfiler) writeProfile(w io.WriteCloser, profileName string) error {
+	_, err := io.WriteString(w, "test "+profileName+"\n")
+	return err
+}
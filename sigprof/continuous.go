@@ -0,0 +1,230 @@
+package sigprof
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ContinuousOptions configures a Continuous profiler created with
+// NewContinuous.
+type ContinuousOptions struct {
+	// Profiles are captured every Interval.
+	Profiles []string
+	// Dir is the directory capture files are written to and pruned
+	// from. It defaults to os.TempDir() if empty.
+	Dir string
+	// Interval is how often profiles are captured.
+	Interval time.Duration
+
+	// MaxFiles, MaxAge and MaxTotalBytes bound the retained capture
+	// files once pruning runs; zero means unbounded.
+	MaxFiles      int
+	MaxAge        time.Duration
+	MaxTotalBytes int64
+}
+
+// Continuous captures the configured profiles on a fixed interval
+// rather than in response to a signal or explicit trigger, pruning old
+// capture files so a long-running process doesn't accumulate them
+// without bound. It's suitable as a lightweight always-on profiler for
+// services.
+type Continuous struct {
+	opts ContinuousOptions
+
+	writerFactory   func(profile string, output outputType) io.WriteCloser
+	profilerFactory func() profiler
+	tickerFactory   func(time.Duration) <-chan time.Time
+
+	// profilerOnce/profiler cache the single profiler instance used for
+	// every tick, so stateful profilers (e.g. deltaProfiler) retain
+	// state across captures instead of starting fresh each time. See
+	// the equivalent caching in Sigprof.getProfiler.
+	profilerOnce sync.Once
+	profiler     profiler
+
+	stopCh chan struct{}
+}
+
+// NewContinuous creates a Continuous profiler configured with opts.
+// Call Start to begin capturing on Interval.
+func NewContinuous(opts ContinuousOptions) *Continuous {
+	if opts.Dir == "" {
+		opts.Dir = os.TempDir()
+	}
+	return &Continuous{
+		opts:            opts,
+		writerFactory:   newDirWriter(opts.Dir),
+		profilerFactory: newDefaultProfiler,
+		tickerFactory:   newTickerChan,
+	}
+}
+
+func newTickerChan(d time.Duration) <-chan time.Time {
+	return time.NewTicker(d).C
+}
+
+// newDirWriter returns a writerFactory that writes file-output profiles
+// into dir instead of the OS default temp directory, so capture files
+// can be found again for pruning.
+func newDirWriter(dir string) func(profile string, output outputType) io.WriteCloser {
+	return func(profile string, output outputType) io.WriteCloser {
+		switch output {
+		case stdoutOutput:
+			return stdoutWriter{}
+		case stderrOutput:
+			return stderrWriter{}
+		default:
+			f, err := ioutil.TempFile(dir, fmt.Sprintf("%s.%s.prof.", filepath.Base(os.Args[0]), profile))
+			if err != nil {
+				log.Printf("failed to create file for %s profile: %v", profile, err)
+				return stderrWriter{}
+			}
+			return f
+		}
+	}
+}
+
+// Start begins capturing profiles on Interval in the background.
+func (c *Continuous) Start() {
+	c.stopCh = make(chan struct{})
+	go c.loop()
+}
+
+// Stop ends the capture loop started by Start.
+func (c *Continuous) Stop() {
+	close(c.stopCh)
+}
+
+func (c *Continuous) loop() {
+	ticks := c.tickerFactory(c.opts.Interval)
+	for {
+		select {
+		case <-ticks:
+			c.captureAll()
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+func (c *Continuous) captureAll() {
+	for _, profile := range c.opts.Profiles {
+		w := c.writerFactory(profile, fileOutput)
+		ok := capture(c.getProfiler(), profile, w)
+		if f, isFile := w.(*os.File); ok && isFile {
+			c.record(profile, f.Name())
+		}
+	}
+	c.prune()
+}
+
+// getProfiler lazily constructs the single profiler instance used for
+// every tick of c.
+func (c *Continuous) getProfiler() profiler {
+	c.profilerOnce.Do(func() {
+		c.profiler = c.profilerFactory()
+	})
+	return c.profiler
+}
+
+// indexEntry describes one capture file tracked in the on-disk index,
+// so that pruning survives a process restart.
+type indexEntry struct {
+	Name    string    `json:"name"`
+	Profile string    `json:"profile"`
+	Time    time.Time `json:"time"`
+	Size    int64     `json:"size"`
+}
+
+func (c *Continuous) indexPath() string {
+	return filepath.Join(c.opts.Dir, ".sigprof-index.json")
+}
+
+func (c *Continuous) loadIndex() []indexEntry {
+	data, err := ioutil.ReadFile(c.indexPath())
+	if err != nil {
+		return nil
+	}
+	var entries []indexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("failed to parse sigprof index: %v", err)
+		return nil
+	}
+	return entries
+}
+
+func (c *Continuous) saveIndex(entries []indexEntry) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		log.Printf("failed to encode sigprof index: %v", err)
+		return
+	}
+	if err := ioutil.WriteFile(c.indexPath(), data, 0644); err != nil {
+		log.Printf("failed to write sigprof index: %v", err)
+	}
+}
+
+func (c *Continuous) record(profile, name string) {
+	entries := c.loadIndex()
+	var size int64
+	if fi, err := os.Stat(name); err == nil {
+		size = fi.Size()
+	}
+	entries = append(entries, indexEntry{Name: name, Profile: profile, Time: time.Now(), Size: size})
+	c.saveIndex(entries)
+}
+
+// prune removes the oldest capture files once they exceed MaxFiles,
+// MaxAge or MaxTotalBytes, and rewrites the index to match.
+func (c *Continuous) prune() {
+	entries := c.loadIndex()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+
+	if c.opts.MaxAge > 0 {
+		now := time.Now()
+		kept := entries[:0]
+		for _, e := range entries {
+			if now.Sub(e.Time) > c.opts.MaxAge {
+				c.removeFile(e)
+				continue
+			}
+			kept = append(kept, e)
+		}
+		entries = kept
+	}
+
+	if c.opts.MaxFiles > 0 {
+		for len(entries) > c.opts.MaxFiles {
+			c.removeFile(entries[0])
+			entries = entries[1:]
+		}
+	}
+
+	if c.opts.MaxTotalBytes > 0 {
+		var total int64
+		for _, e := range entries {
+			total += e.Size
+		}
+		for total > c.opts.MaxTotalBytes && len(entries) > 0 {
+			total -= entries[0].Size
+			c.removeFile(entries[0])
+			entries = entries[1:]
+		}
+	}
+
+	c.saveIndex(entries)
+}
+
+func (c *Continuous) removeFile(e indexEntry) {
+	if err := os.Remove(e.Name); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to prune %s: %v", e.Name, err)
+	}
+}
@@ -0,0 +1,62 @@
+package sigprof
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestContinuousCaptureAndPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sigprof-continuous-")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := NewContinuous(ContinuousOptions{
+		Profiles: []string{"foo"},
+		Dir:      dir,
+		Interval: time.Second,
+		MaxFiles: 2,
+	})
+	c.profilerFactory = func() profiler { return testProfiler{} }
+	c.writerFactory = func(profile string, output outputType) io.WriteCloser {
+		f, err := ioutil.TempFile(dir, profile+".prof.")
+		if err != nil {
+			t.Fatalf("failed to create capture file: %v", err)
+		}
+		return f
+	}
+
+	ticks := make(chan time.Time)
+	c.tickerFactory = func(time.Duration) <-chan time.Time { return ticks }
+
+	c.Start()
+	defer c.Stop()
+
+	for i := 0; i < 3; i++ {
+		ticks <- time.Now()
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	entries := c.loadIndex()
+	if len(entries) != 2 {
+		t.Errorf("expected 2 retained index entries after pruning, got %d", len(entries))
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read capture dir: %v", err)
+	}
+	var profCount int
+	for _, fi := range files {
+		if fi.Name() != ".sigprof-index.json" {
+			profCount++
+		}
+	}
+	if profCount != 2 {
+		t.Errorf("expected 2 capture files on disk after pruning, got %d", profCount)
+	}
+}
@@ -0,0 +1,114 @@
+package sigprof
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/google/pprof/profile"
+)
+
+// deltaProfileNames are the profiles emitted as the difference from the
+// previous capture rather than a cumulative snapshot, configured via
+// the comma-separated SIGPROF_DELTA env var (e.g. "heap,block,mutex").
+var deltaProfileNames = splitEnvSet("SIGPROF_DELTA", "")
+
+func splitEnvSet(name, def string) map[string]bool {
+	v := os.Getenv(name)
+	if v == "" {
+		v = def
+	}
+	set := map[string]bool{}
+	for _, n := range strings.Split(v, ",") {
+		if n == "" {
+			continue
+		}
+		set[n] = true
+	}
+	return set
+}
+
+// writeDeltaBaseline controls whether the first capture of a delta
+// profile is written as-is (the default) or skipped, via
+// SIGPROF_DELTA_BASELINE=skip.
+var writeDeltaBaseline = os.Getenv("SIGPROF_DELTA_BASELINE") != "skip"
+
+// accumulatingProfiles are the profile types that can be emitted as
+// deltas; other names listed in SIGPROF_DELTA pass through unchanged.
+var accumulatingProfiles = map[string]bool{
+	"heap": true, "allocs": true, "block": true, "mutex": true, "contention": true,
+}
+
+type bufferWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (bufferWriteCloser) Close() error { return nil }
+
+// deltaProfiler wraps a profiler and, for the accumulating profile
+// types configured in deltaProfileNames (heap, allocs, block, mutex,
+// contention), emits the difference between the current and previous
+// capture of that profile instead of a cumulative snapshot. All other
+// profiles, including cpu and goroutine, pass through unchanged.
+type deltaProfiler struct {
+	next profiler
+
+	mu   sync.Mutex
+	prev map[string]*profile.Profile
+}
+
+func newDeltaProfiler(next profiler) *deltaProfiler {
+	return &deltaProfiler{next: next, prev: map[string]*profile.Profile{}}
+}
+
+func (d *deltaProfiler) writeProfile(w io.WriteCloser, profileName string) error {
+	if !deltaProfileNames[profileName] || !accumulatingProfiles[profileName] {
+		return d.next.writeProfile(w, profileName)
+	}
+
+	var buf bytes.Buffer
+	if err := d.next.writeProfile(bufferWriteCloser{&buf}, profileName); err != nil {
+		return err
+	}
+	current, err := profile.Parse(&buf)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s profile: %v", profileName, err)
+	}
+
+	d.mu.Lock()
+	prev, haveBaseline := d.prev[profileName]
+	d.prev[profileName] = current
+	d.mu.Unlock()
+
+	if !haveBaseline {
+		if !writeDeltaBaseline {
+			return nil
+		}
+		return current.Write(w)
+	}
+
+	// Scaling prev by -1 and merging it with current yields the
+	// sample-value delta between the two captures.
+	prev.Scale(-1)
+	merged, err := profile.Merge([]*profile.Profile{prev, current})
+	if err != nil {
+		return fmt.Errorf("failed to compute %s profile delta: %v", profileName, err)
+	}
+	merged.DurationNanos = current.TimeNanos - prev.TimeNanos
+	merged.TimeNanos = current.TimeNanos
+	return merged.Write(w)
+}
+
+// newDefaultProfiler returns the profiler used by New and NewContinuous
+// by default: a plain pprofiler, wrapped in a deltaProfiler when
+// SIGPROF_DELTA configures any delta profiles.
+func newDefaultProfiler() profiler {
+	p := newProfiler()
+	if len(deltaProfileNames) == 0 {
+		return p
+	}
+	return newDeltaProfiler(p)
+}
@@ -0,0 +1,54 @@
+package sigprof
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+type fakeProfile struct {
+	p *profile.Profile
+}
+
+func (f fakeProfile) writeProfile(w io.WriteCloser, profileName string) error {
+	return f.p.Write(w)
+}
+
+func TestDeltaProfilerBaseline(t *testing.T) {
+	orig := deltaProfileNames
+	deltaProfileNames = map[string]bool{"heap": true}
+	defer func() { deltaProfileNames = orig }()
+
+	base := &profile.Profile{TimeNanos: time.Now().UnixNano()}
+	d := newDeltaProfiler(fakeProfile{base})
+
+	var buf bytes.Buffer
+	if err := d.writeProfile(bufferWriteCloser{&buf}, "heap"); err != nil {
+		t.Fatalf("writeProfile failed: %v", err)
+	}
+	if buf.Len() == 0 {
+		t.Error("expected baseline capture to be written")
+	}
+	if _, ok := d.prev["heap"]; !ok {
+		t.Error("expected baseline to be recorded for subsequent delta")
+	}
+}
+
+func TestDeltaProfilerPassthrough(t *testing.T) {
+	orig := deltaProfileNames
+	deltaProfileNames = map[string]bool{"heap": true}
+	defer func() { deltaProfileNames = orig }()
+
+	d := newDeltaProfiler(testProfiler{})
+
+	var buf bytes.Buffer
+	if err := d.writeProfile(bufferWriteCloser{&buf}, "goroutine"); err != nil {
+		t.Fatalf("writeProfile failed: %v", err)
+	}
+	if buf.String() != "test goroutine\n" {
+		t.Errorf("expected non-delta profile to pass through unchanged, got %q", buf.String())
+	}
+}
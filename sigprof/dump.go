@@ -0,0 +1,216 @@
+package sigprof
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+var processStart = time.Now()
+
+// dumpProfiles are the pprof profiles included in every dump archive,
+// in addition to the CPU profile and execution trace.
+var dumpProfiles = []string{"goroutine", "heap", "threadcreate", "block", "mutex", "allocs"}
+
+// dumpCPUDuration and dumpTraceDuration are the CPU profile and
+// execution trace sampling durations used by Dump, configurable via
+// SIGPROF_DUMP_CPU_DURATION and SIGPROF_DUMP_TRACE_DURATION since a
+// dump is served synchronously over HTTP and a caller may want it
+// shorter (or longer) than the defaults.
+var dumpCPUDuration = parseDumpDuration("SIGPROF_DUMP_CPU_DURATION", 5*time.Second)
+var dumpTraceDuration = parseDumpDuration("SIGPROF_DUMP_TRACE_DURATION", 5*time.Second)
+
+func parseDumpDuration(envVar string, def time.Duration) time.Duration {
+	s := os.Getenv(envVar)
+	if s == "" {
+		return def
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		log.Printf("invalid %s %q: %v", envVar, s, err)
+		return def
+	}
+	return d
+}
+
+var (
+	dumpersMu sync.Mutex
+	dumpers   = map[string]func(io.Writer) error{}
+)
+
+// RegisterDumper adds an extra file to every diagnostic dump archive,
+// produced by fn under the given name. It lets downstream packages
+// contribute their own diagnostics alongside the built-in profiles.
+func RegisterDumper(name string, fn func(io.Writer) error) {
+	dumpersMu.Lock()
+	defer dumpersMu.Unlock()
+	dumpers[name] = fn
+}
+
+// Handler returns an http.HandlerFunc that writes the diagnostic dump
+// archive produced by Dump to the response, as a gzip attachment.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", dumpFilename()))
+		if err := Dump(w); err != nil {
+			log.Printf("failed to write diagnostic dump: %v", err)
+		}
+	}
+}
+
+// Dump writes a gzipped tar archive of diagnostic information to w: the
+// goroutine, heap, threadcreate, block, mutex and allocs pprof
+// profiles, a sampled CPU profile, a runtime execution trace,
+// runtime.MemStats, os.Args, the process environment, basic host
+// load/CPU info, and any files contributed via RegisterDumper.
+func Dump(w io.Writer) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, name := range dumpProfiles {
+		if err := addTarFunc(tw, name+".prof", writeLookupProfile(name)); err != nil {
+			return err
+		}
+	}
+	if err := addTarFunc(tw, "cpu.prof", writeCPUDump); err != nil {
+		return err
+	}
+	if err := addTarFunc(tw, "trace.out", writeTraceDump); err != nil {
+		return err
+	}
+	if err := addTarFunc(tw, "memstats.txt", writeMemStats); err != nil {
+		return err
+	}
+	if err := addTarFunc(tw, "args.txt", writeArgs); err != nil {
+		return err
+	}
+	if err := addTarFunc(tw, "env.txt", writeEnv); err != nil {
+		return err
+	}
+	if err := addTarFunc(tw, "host.txt", writeHostInfo); err != nil {
+		return err
+	}
+
+	dumpersMu.Lock()
+	extra := make(map[string]func(io.Writer) error, len(dumpers))
+	for name, fn := range dumpers {
+		extra[name] = fn
+	}
+	dumpersMu.Unlock()
+	for name, fn := range extra {
+		if err := addTarFunc(tw, name, fn); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// addTarFunc buffers the output of fn and adds it to tw as a single
+// file entry, since a tar header needs the content length up front.
+func addTarFunc(tw *tar.Writer, name string, fn func(io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := fn(&buf); err != nil {
+		return fmt.Errorf("failed to collect %s: %v", name, err)
+	}
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(buf.Len()),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(buf.Bytes())
+	return err
+}
+
+func writeLookupProfile(name string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		switch name {
+		case "block":
+			setBlockProfileRate()
+		case "mutex":
+			setMutexProfileFraction()
+		}
+		prof := pprof.Lookup(name)
+		if prof == nil {
+			return fmt.Errorf("failed to lookup profile %q", name)
+		}
+		return prof.WriteTo(w, 0)
+	}
+}
+
+func writeCPUDump(w io.Writer) error {
+	if err := pprof.StartCPUProfile(w); err != nil {
+		return fmt.Errorf("failed to start CPU profiling: %v", err)
+	}
+	time.Sleep(dumpCPUDuration)
+	pprof.StopCPUProfile()
+	return nil
+}
+
+func writeTraceDump(w io.Writer) error {
+	if err := trace.Start(w); err != nil {
+		return fmt.Errorf("failed to start execution trace: %v", err)
+	}
+	time.Sleep(dumpTraceDuration)
+	trace.Stop()
+	return nil
+}
+
+func writeMemStats(w io.Writer) error {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	_, err := fmt.Fprintf(w, "%+v\n", m)
+	return err
+}
+
+func writeArgs(w io.Writer) error {
+	_, err := fmt.Fprintln(w, os.Args)
+	return err
+}
+
+func writeEnv(w io.Writer) error {
+	for _, kv := range os.Environ() {
+		if _, err := fmt.Fprintln(w, kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHostInfo(w io.Writer) error {
+	fmt.Fprintf(w, "GOOS=%s GOARCH=%s NumCPU=%d GOMAXPROCS=%d\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU(), runtime.GOMAXPROCS(0))
+	if load, err := ioutil.ReadFile("/proc/loadavg"); err == nil {
+		fmt.Fprintf(w, "loadavg: %s", load)
+	}
+	return nil
+}
+
+// dumpFilename encodes the executable name, start time, and elapsed
+// uptime into the archive's suggested filename.
+func dumpFilename() string {
+	uptime := time.Since(processStart)
+	return fmt.Sprintf("%s.%s.%s.tar.gz",
+		filepath.Base(os.Args[0]),
+		processStart.Format("20060102T150405"),
+		uptime.Truncate(time.Second))
+}
@@ -0,0 +1,73 @@
+package sigprof
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	origCPU, origTrace := dumpCPUDuration, dumpTraceDuration
+	dumpCPUDuration, dumpTraceDuration = 0, 0
+	defer func() {
+		dumpCPUDuration, dumpTraceDuration = origCPU, origTrace
+	}()
+
+	RegisterDumper("extra.txt", func(w io.Writer) error {
+		_, err := io.WriteString(w, "extra diagnostic data\n")
+		return err
+	})
+	defer func() {
+		dumpersMu.Lock()
+		delete(dumpers, "extra.txt")
+		dumpersMu.Unlock()
+	}()
+
+	var buf bytes.Buffer
+	if err := Dump(&buf); err != nil {
+		t.Fatalf("Dump failed: %v", err)
+	}
+
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("failed to open gzip reader: %v", err)
+	}
+	tr := tar.NewReader(gr)
+
+	names := map[string]bool{}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read tar entry: %v", err)
+		}
+		contents, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("failed to read contents of %q: %v", hdr.Name, err)
+		}
+		if hdr.Name == "extra.txt" && string(contents) != "extra diagnostic data\n" {
+			t.Errorf("unexpected extra.txt contents: %q", string(contents))
+		}
+		names[hdr.Name] = true
+	}
+
+	for _, name := range []string{"goroutine.prof", "heap.prof", "threadcreate.prof", "block.prof",
+		"mutex.prof", "allocs.prof", "cpu.prof", "trace.out", "memstats.txt", "args.txt", "env.txt",
+		"host.txt", "extra.txt"} {
+		if !names[name] {
+			t.Errorf("missing expected archive entry %q", name)
+		}
+	}
+}
+
+func TestDumpFilename(t *testing.T) {
+	name := dumpFilename()
+	if name == "" {
+		t.Fatal("empty dump filename")
+	}
+}
@@ -0,0 +1,181 @@
+package sigprof
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"time"
+)
+
+type stderrWriter struct{}
+
+// Write implements io.Writer.
+func (w stderrWriter) Write(p []byte) (int, error) {
+	return os.Stderr.Write(p)
+}
+
+// Close implements io.Closer.
+func (w stderrWriter) Close() error {
+	return nil
+}
+
+type stdoutWriter struct{}
+
+// Write implements io.Writer.
+func (w stdoutWriter) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// Close implements io.Closer.
+func (w stdoutWriter) Close() error {
+	return nil
+}
+
+type outputType string
+
+const (
+	stdoutOutput = outputType("stdout")
+	stderrOutput = outputType("stderr")
+	fileOutput   = outputType("file")
+)
+
+func newWriter(profile string, output outputType) io.WriteCloser {
+	switch output {
+	case "file":
+		f, err := ioutil.TempFile("", fmt.Sprintf("%s.%s.prof.", filepath.Base(os.Args[0]), profile))
+		if err != nil {
+			log.Printf("failed to create file for %s profile: %v", profile, err)
+			return stderrWriter{}
+		}
+		log.Printf("writing %s profile to %s", profile, f.Name())
+		return f
+	case "stdout":
+		return stdoutWriter{}
+	case "stderr":
+		return stderrWriter{}
+	default:
+		return stderrWriter{}
+	}
+}
+
+type profiler interface {
+	writeProfile(w io.WriteCloser, profileName string) error
+}
+
+type pprofiler struct{}
+
+func (p *pprofiler) writeProfile(w io.WriteCloser, profileName string) error {
+	switch profileName {
+	case "cpu":
+		return p.cpuProfile(w)
+	case "trace":
+		return p.traceProfile(w)
+	case "block":
+		setBlockProfileRate()
+	case "mutex":
+		setMutexProfileFraction()
+	}
+	prof := pprof.Lookup(profileName)
+	if prof == nil {
+		return fmt.Errorf("failed to lookup profile %q", profileName)
+	}
+	return prof.WriteTo(w, 1)
+}
+
+var cpuProfileDuration = 30 * time.Second
+
+func (p *pprofiler) cpuProfile(w io.WriteCloser) error {
+	err := pprof.StartCPUProfile(w)
+	if err != nil {
+		return fmt.Errorf("failed to start CPU profiling: %v", err)
+	}
+	go func() {
+		time.Sleep(cpuProfileDuration)
+		log.Println("cpu profile complete")
+		pprof.StopCPUProfile()
+		err := w.Close()
+		if err != nil {
+			log.Printf("error closing file: %v", err)
+		}
+	}()
+	return nil
+}
+
+var traceDuration = 30 * time.Second
+
+func (p *pprofiler) traceProfile(w io.WriteCloser) error {
+	err := trace.Start(w)
+	if err != nil {
+		return fmt.Errorf("failed to start execution trace: %v", err)
+	}
+	go func() {
+		time.Sleep(traceDuration)
+		log.Println("execution trace complete")
+		trace.Stop()
+		err := w.Close()
+		if err != nil {
+			log.Printf("error closing file: %v", err)
+		}
+	}()
+	return nil
+}
+
+// setBlockProfileRate enables the block profile at the rate given by
+// SIGPROF_BLOCK_RATE (see runtime.SetBlockProfileRate), defaulting to 1
+// if unset or invalid.
+func setBlockProfileRate() {
+	rate := 1
+	if rateStr := os.Getenv(`SIGPROF_BLOCK_RATE`); rateStr != "" {
+		if n, err := strconv.Atoi(rateStr); err == nil {
+			rate = n
+		}
+	}
+	runtime.SetBlockProfileRate(rate)
+}
+
+// setMutexProfileFraction enables the mutex profile at the fraction
+// given by SIGPROF_MUTEX_FRACTION (see runtime.SetMutexProfileFraction),
+// defaulting to 1 if unset or invalid.
+func setMutexProfileFraction() {
+	fraction := 1
+	if fractionStr := os.Getenv(`SIGPROF_MUTEX_FRACTION`); fractionStr != "" {
+		if n, err := strconv.Atoi(fractionStr); err == nil {
+			fraction = n
+		}
+	}
+	runtime.SetMutexProfileFraction(fraction)
+}
+
+func newProfiler() profiler {
+	return &pprofiler{}
+}
+
+// capture writes the named profile from p to w, returning false if the
+// write failed. On failure, a file output is closed and removed rather
+// than left behind half-written.
+func capture(p profiler, profileName string, w io.WriteCloser) bool {
+	err := p.writeProfile(w, profileName)
+	if err != nil {
+		log.Printf("failed to write %s profile: %v", profileName, err)
+		if f, ok := w.(*os.File); ok {
+			if cerr := f.Close(); cerr != nil {
+				log.Printf("error closing file: %v", cerr)
+			}
+			if rerr := os.Remove(f.Name()); rerr != nil {
+				log.Printf("cleanup error removing file: %v", rerr)
+			}
+		}
+		return false
+	}
+	if profileName != "cpu" && profileName != "trace" {
+		w.Close()
+	}
+	return true
+}
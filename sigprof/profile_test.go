@@ -0,0 +1,150 @@
+package sigprof
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPprofilerDispatch(t *testing.T) {
+	p := newProfiler()
+	// Text (debug=1) headers written by runtime/pprof for each profile.
+	headers := map[string]string{
+		"goroutine": "goroutine profile",
+		"heap":      "heap profile",
+		"block":     "--- contention:",
+		"mutex":     "--- mutex:",
+	}
+	for _, profile := range []string{"goroutine", "heap", "block", "mutex"} {
+		var buf bytes.Buffer
+		if err := p.writeProfile(bufferCloser{&buf}, profile); err != nil {
+			t.Errorf("%s: writeProfile failed: %v", profile, err)
+			continue
+		}
+		if !strings.Contains(buf.String(), headers[profile]) {
+			t.Errorf("%s: unexpected profile contents: %q", profile, buf.String())
+		}
+	}
+}
+
+func TestCPUProfile(t *testing.T) {
+	origDuration := cpuProfileDuration
+	cpuProfileDuration = 0
+	defer func() { cpuProfileDuration = origDuration }()
+
+	var buf bytes.Buffer
+	p := newProfiler()
+	if err := p.writeProfile(bufferCloser{&buf}, "cpu"); err != nil {
+		t.Fatalf("writeProfile failed: %v", err)
+	}
+
+	// Try to start another CPU profile. This will fail if the above
+	// triggered profile is still running.
+	var profCompleted bool
+	for i := 0; i < 5; i++ {
+		if err := pprof.StartCPUProfile(ioutil.Discard); err == nil {
+			pprof.StopCPUProfile()
+			profCompleted = true
+			break
+		}
+		t.Logf("cpu profile likely still running, sleeping %ds", i)
+		time.Sleep(time.Duration(i) * time.Second)
+	}
+	if !profCompleted {
+		t.Fatal("timeout waiting for cpu profile to complete")
+	}
+
+	if buf.Len() == 0 {
+		t.Errorf("empty profile contents")
+	}
+}
+
+func TestTraceProfile(t *testing.T) {
+	origDuration := traceDuration
+	traceDuration = 0
+	defer func() { traceDuration = origDuration }()
+
+	var buf bytes.Buffer
+	p := newProfiler()
+	if err := p.writeProfile(bufferCloser{&buf}, "trace"); err != nil {
+		t.Fatalf("writeProfile failed: %v", err)
+	}
+
+	// Try to start another execution trace. This will fail if the above
+	// triggered trace is still running.
+	var traceCompleted bool
+	for i := 0; i < 5; i++ {
+		if err := trace.Start(ioutil.Discard); err == nil {
+			trace.Stop()
+			traceCompleted = true
+			break
+		}
+		t.Logf("execution trace likely still running, sleeping %ds", i)
+		time.Sleep(time.Duration(i) * time.Second)
+	}
+	if !traceCompleted {
+		t.Fatal("timeout waiting for execution trace to complete")
+	}
+
+	if buf.Len() == 0 {
+		t.Errorf("empty profile contents")
+	}
+}
+
+func TestNewWriter(t *testing.T) {
+	stdout := newWriter("blips", "stdout")
+	if _, ok := stdout.(stdoutWriter); !ok {
+		t.Errorf("stdout: got a %T instead", stdout)
+	}
+	stderr := newWriter("blops", "stderr")
+	if _, ok := stderr.(stderrWriter); !ok {
+		t.Errorf("stderr: got a %T instead", stderr)
+	}
+	whatever := newWriter("blups", "whatever")
+	if _, ok := whatever.(stderrWriter); !ok {
+		t.Errorf("default: got a %T instead", whatever)
+	}
+	file := newWriter("nitpicks", "file")
+	if f, ok := file.(*os.File); !ok {
+		t.Errorf("file: got a %T instead", file)
+	} else {
+		defer os.Remove(f.Name())
+		defer file.Close()
+		if !strings.Contains(filepath.Base(f.Name()), "nitpicks.prof.") {
+			t.Errorf("file: unexpected file name %q", f.Name())
+		}
+	}
+}
+
+type errorProfiler struct{}
+
+func (errorProfiler) writeProfile(w io.WriteCloser, profileName string) error {
+	return errors.New("testing write failed")
+}
+
+func TestCaptureWriteError(t *testing.T) {
+	f, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	fileName := f.Name()
+	defer os.Remove(fileName)
+
+	if ok := capture(errorProfiler{}, "blops", f); ok {
+		t.Error("expected capture to report failure")
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Fatal("profiler error failed to clean up output file")
+	}
+	if err := f.Close(); err == nil {
+		t.Fatal("profiler left file open")
+	}
+}
@@ -0,0 +1,106 @@
+// +build windows
+
+package sigprof
+
+import (
+	"log"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	kernel32                  = windows.NewLazySystemDLL("kernel32.dll")
+	procSetConsoleCtrlHandler = kernel32.NewProc("SetConsoleCtrlHandler")
+)
+
+// Win32 console control event codes, passed to the handler registered
+// via setConsoleCtrlHandler.
+const (
+	ctrlCEvent     = 0
+	ctrlBreakEvent = 1
+)
+
+func init() {
+	if cpuDurationStr := os.Getenv(`SIGPROF_CPU_DURATION`); cpuDurationStr != "" {
+		if d, err := time.ParseDuration(cpuDurationStr); err == nil {
+			cpuProfileDuration = d
+		} else {
+			log.Printf("invalid SIGPROF_CPU_DURATION %q: %v", cpuDurationStr, err)
+		}
+	}
+
+	opts := Options{
+		Triggers: map[string][]string{
+			"interrupt": splitEnvList("SIGPROF_INTERRUPT", "goroutine"),
+		},
+		Output: os.Getenv("SIGPROF_OUT"),
+	}
+	New(opts).Start()
+}
+
+func splitEnvList(name, def string) []string {
+	v := os.Getenv(name)
+	if v == "" {
+		v = def
+	}
+	return strings.Split(v, ",")
+}
+
+// setConsoleCtrlHandler adds or removes handler (a callback created with
+// syscall.NewCallback) as the process's console control handler via the
+// kernel32 SetConsoleCtrlHandler API, which golang.org/x/sys/windows does
+// not wrap.
+func setConsoleCtrlHandler(handler uintptr, add bool) error {
+	var addArg uintptr
+	if add {
+		addArg = 1
+	}
+	ret, _, err := procSetConsoleCtrlHandler.Call(handler, addArg)
+	if ret == 0 {
+		return err
+	}
+	return nil
+}
+
+// start begins listening for CTRL_C_EVENT and CTRL_BREAK_EVENT (Ctrl-C
+// and Ctrl-Break) via a console control handler and triggers the
+// profiles configured under the "interrupt" key of s.opts.Triggers.
+//
+// Windows has no signal analogous to SIGUSR1/SIGUSR2, so unlike the
+// Unix start in sigprof.go, this relies on the console control handler
+// rather than os/signal.
+func (s *Sigprof) start() {
+	c := make(chan struct{}, 1)
+	handler := syscall.NewCallback(func(ctrlType uint32) uintptr {
+		switch ctrlType {
+		case ctrlCEvent, ctrlBreakEvent:
+			select {
+			case c <- struct{}{}:
+			default:
+			}
+			return 1
+		}
+		return 0
+	})
+	if err := setConsoleCtrlHandler(handler, true); err != nil {
+		log.Printf("sigprof: failed to register console control handler: %v", err)
+		return
+	}
+
+	s.stopCh = make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-c:
+				s.Trigger(s.opts.Triggers["interrupt"]...)
+			case <-s.stopCh:
+				setConsoleCtrlHandler(handler, false)
+				return
+			}
+		}
+	}()
+}